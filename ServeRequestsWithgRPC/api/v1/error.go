@@ -0,0 +1,26 @@
+package log_v1
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrOffsetOutOfRange is returned when a requested offset doesn't exist in
+// the log, either because it was never written or because it's been
+// removed by retention/compaction.
+type ErrOffsetOutOfRange struct {
+	Offset uint64
+}
+
+func (e ErrOffsetOutOfRange) GRPCStatus() *status.Status {
+	return status.New(
+		codes.NotFound,
+		fmt.Sprintf("offset out of range: %d", e.Offset),
+	)
+}
+
+func (e ErrOffsetOutOfRange) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"context"
+
+	api "github.com/Geun-Oh/distributed-services-with-go/ServeRequestsWithgRPC/api/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// streamingCommitLog is implemented by a CommitLog that can push records to
+// a caller as they're appended, rather than only serving point reads. The
+// server's CommitLog (a *log.Log) satisfies it once replication is wired
+// up; CommitLog implementations used only for tests may not.
+type streamingCommitLog interface {
+	Stream(ctx context.Context, fromOffset uint64, send func(*api.Record) error) error
+}
+
+// ConsumeStream is the leader side of replication: it pushes every record
+// from req.Offset onward to the caller, blocking when the log is caught
+// up, until the client disconnects or cancels.
+func (s *grpcServer) ConsumeStream(req *api.ConsumeRequest, stream api.Log_ConsumeStreamServer) error {
+	sl, ok := s.CommitLog.(streamingCommitLog)
+	if !ok {
+		return status.Error(codes.Unimplemented, "commit log does not support streaming replication")
+	}
+	return sl.Stream(stream.Context(), req.Offset, func(record *api.Record) error {
+		return stream.Send(&api.ConsumeResponse{Record: record})
+	})
+}
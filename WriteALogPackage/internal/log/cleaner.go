@@ -0,0 +1,259 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	api "github.com/Geun-Oh/distributed-services-with-go/ServeRequestsWithgRPC/api/v1"
+	"github.com/golang/protobuf/proto"
+)
+
+// defaultCleanupInterval is used when Config.CleanupInterval is unset.
+const defaultCleanupInterval = time.Minute
+
+// RunCleaner scans the log's non-active segments every CleanupInterval and
+// applies Config.Policy to them, until ctx is canceled. Callers run it in its
+// own goroutine, e.g. go log.RunCleaner(ctx).
+func (l *Log) RunCleaner(ctx context.Context) {
+	interval := l.Config.CleanupInterval
+	if interval == 0 {
+		interval = defaultCleanupInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.CompactNow()
+		}
+	}
+}
+
+// CompactNow runs one pass of the configured cleanup policy immediately,
+// without waiting for the next CleanupInterval tick. It is exported so tests
+// can exercise cleanup deterministically.
+//
+// The actual compaction/deletion I/O in compactSegment and s.Remove runs
+// without l.mu held, since rewriting a segment can take a while and must not
+// block concurrent Appends. Only the segments snapshotted below (every
+// segment that was non-active when this pass started) are candidates, and
+// l.segments is never replaced wholesale afterwards: each touched segment is
+// swapped or dropped in place against the CURRENT l.segments, so a segment
+// rollover that happens concurrently (Log.Append sealing the snapshotted
+// active segment and/or creating new ones) is preserved rather than
+// silently discarded.
+func (l *Log) CompactNow() error {
+	l.mu.Lock()
+	active := l.activeSegment
+	segments := make([]*segment, 0, len(l.segments))
+	for _, s := range l.segments {
+		if s != active {
+			segments = append(segments, s)
+		}
+	}
+	l.mu.Unlock()
+
+	now := time.Now()
+	replacement := make(map[*segment]*segment, len(segments))
+	for _, s := range segments {
+		expired, err := l.segmentExpired(s, now)
+		if err != nil {
+			return err
+		}
+		switch l.Config.Policy {
+		case Delete:
+			if expired {
+				if err := s.Remove(); err != nil {
+					return err
+				}
+				replacement[s] = nil
+				continue
+			}
+		case Compact:
+			rewritten, err := l.compactSegment(s)
+			if err != nil {
+				return err
+			}
+			replacement[s] = rewritten
+			continue
+		case Both:
+			if expired {
+				if err := s.Remove(); err != nil {
+					return err
+				}
+				replacement[s] = nil
+				continue
+			}
+			rewritten, err := l.compactSegment(s)
+			if err != nil {
+				return err
+			}
+			replacement[s] = rewritten
+			continue
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	current := make([]*segment, 0, len(l.segments))
+	for _, s := range l.segments {
+		repl, touched := replacement[s]
+		if !touched {
+			current = append(current, s) // untouched by this pass: active at snapshot time, or appended since
+			continue
+		}
+		if repl != nil {
+			current = append(current, repl)
+		}
+	}
+	l.segments = current
+	return nil
+}
+
+// segmentExpired reports whether every record in s is older than
+// Config.RetentionDuration. A zero RetentionDuration disables time-based
+// retention, so segments never expire.
+func (l *Log) segmentExpired(s *segment, now time.Time) (bool, error) {
+	if l.Config.RetentionDuration == 0 {
+		return false, nil
+	}
+	newest, ok, err := s.newestTimestamp()
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, nil
+	}
+	cutoff := now.Add(-l.Config.RetentionDuration).UnixNano()
+	return newest < cutoff, nil
+}
+
+// newestTimestamp returns the largest Record.Timestamp stored in the
+// segment, and false if the segment holds no records.
+func (s *segment) newestTimestamp() (int64, bool, error) {
+	var (
+		newest int64
+		found  bool
+	)
+	err := s.forEachRecord(func(record *api.Record) error {
+		if !found || record.Timestamp > newest {
+			newest = record.Timestamp
+			found = true
+		}
+		return nil
+	})
+	return newest, found, err
+}
+
+// forEachRecord replays every record currently stored in the segment, in
+// offset order, calling fn with each one.
+func (s *segment) forEachRecord(fn func(*api.Record) error) error {
+	st, idx, err := s.acquire()
+	if err != nil {
+		return err
+	}
+	defer s.release()
+
+	width := s.nextOffset - s.baseOffset
+	for rel := uint32(0); uint64(rel) < width; rel++ {
+		_, pos, err := idx.Read(int64(rel))
+		if err != nil {
+			return err
+		}
+		if pos == tombstonePos {
+			continue
+		}
+		p, err := st.Read(pos)
+		if err != nil {
+			return err
+		}
+		record := &api.Record{}
+		if err := proto.Unmarshal(p, record); err != nil {
+			return err
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+compactSegment rewrites s so that only the newest record for each key
+survives. A tombstone (a record whose Value is empty) drops its key from the
+output entirely. Records without a Key (Key is nil) are never compacted
+away, since there is nothing to deduplicate them against.
+
+Every surviving record keeps its original Offset: Offset is part of a
+record's identity (callers cache it, replication compares it across nodes),
+so compaction must not renumber it. The index format still requires a dense
+run of entries, so a compacted-away relative offset isn't simply omitted —
+it's rewritten as a tombstone index entry (see appendTombstoneEntry) that
+keeps the slot without pointing at any store data.
+*/
+func (l *Log) compactSegment(s *segment) (*segment, error) {
+	width := s.nextOffset - s.baseOffset
+	survivors := make([]*api.Record, width)
+	latest := make(map[string]uint32)
+
+	err := s.forEachRecord(func(record *api.Record) error {
+		rel := uint32(record.Offset - s.baseOffset)
+		if len(record.Key) == 0 {
+			survivors[rel] = record
+			return nil
+		}
+		key := string(record.Key)
+		if prev, ok := latest[key]; ok {
+			survivors[prev] = nil // superseded by this newer record for the key
+		}
+		latest[key] = rel
+		survivors[rel] = record
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := os.MkdirTemp(l.Dir, fmt.Sprintf("compact-%d-", s.baseOffset))
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	rewritten, err := newSegment(tmpDir, s.baseOffset, s.config)
+	if err != nil {
+		return nil, err
+	}
+	for rel, record := range survivors {
+		if record == nil || (len(record.Key) > 0 && len(record.Value) == 0) {
+			if err := rewritten.appendTombstoneEntry(uint32(rel)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := rewritten.appendAtRelativeOffset(uint32(rel), record); err != nil {
+			return nil, err
+		}
+	}
+	if err := rewritten.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := s.Remove(); err != nil {
+		return nil, err
+	}
+	for _, ext := range []string{".store", ".index"} {
+		from := path.Join(tmpDir, fmt.Sprintf("%d%s", s.baseOffset, ext))
+		to := path.Join(l.Dir, fmt.Sprintf("%d%s", s.baseOffset, ext))
+		if err := os.Rename(from, to); err != nil {
+			return nil, err
+		}
+	}
+	return newSegment(l.Dir, s.baseOffset, s.config)
+}
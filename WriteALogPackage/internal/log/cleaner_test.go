@@ -0,0 +1,81 @@
+package log
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	api "github.com/Geun-Oh/distributed-services-with-go/ServeRequestsWithgRPC/api/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleaner(t *testing.T) {
+	for scenario, fn := range map[string]func(t *testing.T, log *Log){
+		"delete expires old segments":       testCleanerDelete,
+		"compact keeps only newest per key": testCleanerCompact,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			dir, err := os.MkdirTemp("", "cleaner-test")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			c := Config{}
+			c.Segment.MaxStoreBytes = 1024
+
+			fn(t, mustNewLog(t, dir, c))
+		})
+	}
+}
+
+func mustNewLog(t *testing.T, dir string, c Config) *Log {
+	t.Helper()
+	l, err := NewLog(dir, c)
+	require.NoError(t, err)
+	return l
+}
+
+func testCleanerDelete(t *testing.T, log *Log) {
+	log.Config.Policy = Delete
+	log.Config.RetentionDuration = time.Millisecond
+
+	_, err := log.Append(&api.Record{Value: []byte("old")})
+	require.NoError(t, err)
+	require.NoError(t, log.newSegment(log.activeSegment.nextOffset))
+
+	time.Sleep(5 * time.Millisecond)
+	_, err = log.Append(&api.Record{Value: []byte("new")})
+	require.NoError(t, err)
+
+	require.NoError(t, log.CompactNow())
+
+	lowest, err := log.LowestOffset()
+	require.NoError(t, err)
+	require.Equal(t, log.activeSegment.baseOffset, lowest)
+}
+
+func testCleanerCompact(t *testing.T, log *Log) {
+	log.Config.Policy = Compact
+
+	_, err := log.Append(&api.Record{Key: []byte("k"), Value: []byte("v1")})
+	require.NoError(t, err)
+	_, err = log.Append(&api.Record{Key: []byte("k"), Value: []byte("v2")})
+	require.NoError(t, err)
+	require.NoError(t, log.newSegment(log.activeSegment.nextOffset))
+	_, err = log.Append(&api.Record{Value: []byte("trigger")})
+	require.NoError(t, err)
+
+	require.NoError(t, log.CompactNow())
+
+	// v1 (offset 0) was superseded by v2 (offset 1) and is compacted away,
+	// but offsets are never renumbered: v2 must still read back at its
+	// original offset 1, and offset 0 must now read as out of range rather
+	// than silently returning v2's value.
+	_, err = log.Read(0)
+	require.Error(t, err)
+	_, ok := err.(api.ErrOffsetOutOfRange)
+	require.True(t, ok)
+
+	read, err := log.Read(1)
+	require.NoError(t, err)
+	require.Equal(t, []byte("v2"), read.Value)
+}
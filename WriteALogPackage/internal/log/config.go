@@ -0,0 +1,59 @@
+package log
+
+import "time"
+
+// Policy selects which background cleanup behavior RunCleaner applies to a
+// Log's non-active segments. The active segment is never deleted or
+// compacted, regardless of policy.
+type Policy int
+
+const (
+	// Delete removes whole segments once every record they hold is older
+	// than RetentionDuration. It is the zero value.
+	Delete Policy = iota
+	// Compact rewrites each segment on its own so that, for each record
+	// key, only the most recently written value within that segment
+	// survives. A record with an empty value is a tombstone and removes
+	// the key from its segment. Older values for the same key held in
+	// other segments are not removed.
+	Compact
+	// Both applies Delete and Compact: a segment past its retention
+	// window is deleted outright, otherwise it is compacted.
+	Both
+)
+
+type Config struct {
+	Segment struct {
+		MaxStoreBytes uint64
+		MaxIndexBytes uint64
+		InitialOffset uint64
+	}
+
+	// Policy selects the cleanup behavior RunCleaner and CompactNow
+	// apply to non-active segments.
+	Policy Policy
+
+	// RetentionDuration is how long after its newest Record.Timestamp a
+	// segment is kept before Delete removes it. Append does not fill in
+	// Timestamp, so records stored without one count as expired. Zero
+	// disables time-based retention.
+	RetentionDuration time.Duration
+
+	// CleanupInterval is how often RunCleaner scans segments for
+	// cleanup. Defaults to one minute when zero.
+	CleanupInterval time.Duration
+
+	// SegmentIdleTimeout is how long a non-active segment's store and
+	// index stay open after their last reader releases them before the
+	// underlying files are actually closed. Defaults to one minute when
+	// zero.
+	SegmentIdleTimeout time.Duration
+
+	// MaxOpenSegments caps how many idle non-active segments may keep
+	// their files mapped. The cap is checked only when the log rolls over
+	// to a new segment, which closes the least-recently-used idle
+	// segments until it holds. Segments reopened by reads between
+	// rollovers are not counted against it and close on their idle
+	// timeout instead. Zero means unlimited.
+	MaxOpenSegments int
+}
@@ -0,0 +1,190 @@
+package log
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	api "github.com/Geun-Oh/distributed-services-with-go/ServeRequestsWithgRPC/api/v1"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// ErrInvalidRef is returned by AppendStream when ref isn't safe to use as a
+// single path component, e.g. because it's empty, contains a path
+// separator, or is "." or "..".
+type ErrInvalidRef struct {
+	Ref string
+}
+
+func (e ErrInvalidRef) Error() string {
+	return fmt.Sprintf("log: content: invalid ref %q", e.Ref)
+}
+
+// ErrMismatchedSize is returned by WriteHandle.Commit when the number of
+// bytes written doesn't match the size declared to AppendStream.
+type ErrMismatchedSize struct {
+	Ref      string
+	Expected int64
+	Actual   int64
+}
+
+func (e ErrMismatchedSize) Error() string {
+	return fmt.Sprintf("log: content %q: expected %d bytes, got %d", e.Ref, e.Expected, e.Actual)
+}
+
+// ErrMismatchedDigest is returned by WriteHandle.Commit when the SHA-256 of
+// the bytes written doesn't match the digest declared to AppendStream.
+type ErrMismatchedDigest struct {
+	Ref      string
+	Expected digest.Digest
+	Actual   digest.Digest
+}
+
+func (e ErrMismatchedDigest) Error() string {
+	return fmt.Sprintf("log: content %q: expected digest %s, got %s", e.Ref, e.Expected, e.Actual)
+}
+
+// WriteHandle accumulates a single large record's bytes on disk so a
+// producer can stream it in chunks (e.g. a multi-MB record sent over an
+// unreliable gRPC stream) without buffering the whole payload in memory.
+type WriteHandle interface {
+	// Write appends p at offset, which must equal the handle's current
+	// length; resumable writes continue from there rather than
+	// skipping ahead or overwriting.
+	Write(p []byte, offset int64) error
+	// Commit validates the accumulated bytes' length and digest, then
+	// appends them to the log's active segment as a single Record and
+	// returns its offset.
+	Commit() (uint64, error)
+}
+
+type writeHandle struct {
+	log    *Log
+	ref    string
+	total  int64
+	expect digest.Digest
+	path   string
+
+	mu     sync.Mutex
+	file   *os.File
+	length int64
+	hasher hash.Hash
+}
+
+// validateRef rejects any ref that isn't safe to join onto a directory as a
+// single path component. ref comes from the producer over the network, so
+// without this a ref like "../../etc/cron.d/evil" or an absolute path could
+// make AppendStream write outside Dir/ingest entirely.
+func validateRef(ref string) error {
+	if ref == "" || ref == "." || ref == ".." ||
+		filepath.IsAbs(ref) || strings.ContainsAny(ref, `/\`) {
+		return ErrInvalidRef{Ref: ref}
+	}
+	return nil
+}
+
+/*
+AppendStream opens a resumable, content-addressable write for ref: the
+caller streams the payload in over one or more Write calls and Commit
+validates it against total and expected before appending it to the log as a
+single Record. The accumulated bytes live in a temp file under
+Dir/ingest/<ref> until Commit succeeds, at which point the file is removed.
+
+Calling AppendStream again with the same ref before a successful Commit
+(e.g. after the producer or this process crashed mid-upload) reopens that
+temp file and returns a handle positioned at its current length, so the
+client resumes from there instead of restarting the whole transfer.
+*/
+func (l *Log) AppendStream(ref string, total int64, expected digest.Digest) (WriteHandle, error) {
+	if err := validateRef(ref); err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(l.Dir, "ingest")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, ref)
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	h := sha256.New()
+	if info.Size() > 0 {
+		if _, err := io.Copy(h, io.NewSectionReader(f, 0, info.Size())); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	return &writeHandle{
+		log:    l,
+		ref:    ref,
+		total:  total,
+		expect: expected,
+		path:   path,
+		file:   f,
+		length: info.Size(),
+		hasher: h,
+	}, nil
+}
+
+func (h *writeHandle) Write(p []byte, offset int64) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if offset != h.length {
+		return fmt.Errorf(
+			"log: content %q: write at offset %d, but %d bytes are already on disk; resume from there",
+			h.ref, offset, h.length,
+		)
+	}
+	n, err := h.file.WriteAt(p, offset)
+	if err != nil {
+		return err
+	}
+	h.hasher.Write(p[:n])
+	h.length += int64(n)
+	return nil
+}
+
+func (h *writeHandle) Commit() (uint64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.length != h.total {
+		return 0, ErrMismatchedSize{Ref: h.ref, Expected: h.total, Actual: h.length}
+	}
+	actual := digest.NewDigest(digest.SHA256, h.hasher)
+	if h.expect != "" && actual != h.expect {
+		return 0, ErrMismatchedDigest{Ref: h.ref, Expected: h.expect, Actual: actual}
+	}
+
+	if _, err := h.file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	value := make([]byte, h.length)
+	if _, err := io.ReadFull(h.file, value); err != nil {
+		return 0, err
+	}
+
+	off, err := h.log.Append(&api.Record{Value: value})
+	if err != nil {
+		return 0, err
+	}
+
+	h.file.Close()
+	os.Remove(h.path)
+	return off, nil
+}
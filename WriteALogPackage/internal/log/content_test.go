@@ -0,0 +1,108 @@
+package log
+
+import (
+	"os"
+	"testing"
+
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendStream(t *testing.T) {
+	for scenario, fn := range map[string]func(t *testing.T, log *Log){
+		"commit succeeds when size and digest match": testAppendStreamCommit,
+		"commit rejects a size mismatch":              testAppendStreamSizeMismatch,
+		"commit rejects a digest mismatch":             testAppendStreamDigestMismatch,
+		"reopening the same ref resumes from the current length": testAppendStreamResume,
+		"rejects a path-traversal ref":                            testAppendStreamRejectsPathTraversal,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			dir, err := os.MkdirTemp("", "content-test")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			l, err := NewLog(dir, Config{})
+			require.NoError(t, err)
+
+			fn(t, l)
+		})
+	}
+}
+
+func testAppendStreamCommit(t *testing.T, log *Log) {
+	payload := []byte("hello distributed world")
+	want := digest.FromBytes(payload)
+
+	h, err := log.AppendStream("blob-1", int64(len(payload)), want)
+	require.NoError(t, err)
+	require.NoError(t, h.Write(payload[:10], 0))
+	require.NoError(t, h.Write(payload[10:], 10))
+
+	off, err := h.Commit()
+	require.NoError(t, err)
+
+	record, err := log.Read(off)
+	require.NoError(t, err)
+	require.Equal(t, payload, record.Value)
+}
+
+func testAppendStreamSizeMismatch(t *testing.T, log *Log) {
+	payload := []byte("short")
+	h, err := log.AppendStream("blob-2", int64(len(payload)+1), digest.FromBytes(payload))
+	require.NoError(t, err)
+	require.NoError(t, h.Write(payload, 0))
+
+	_, err = h.Commit()
+	require.Error(t, err)
+	_, ok := err.(ErrMismatchedSize)
+	require.True(t, ok)
+}
+
+func testAppendStreamDigestMismatch(t *testing.T, log *Log) {
+	payload := []byte("payload")
+	h, err := log.AppendStream("blob-3", int64(len(payload)), digest.FromBytes([]byte("different")))
+	require.NoError(t, err)
+	require.NoError(t, h.Write(payload, 0))
+
+	_, err = h.Commit()
+	require.Error(t, err)
+	_, ok := err.(ErrMismatchedDigest)
+	require.True(t, ok)
+}
+
+func testAppendStreamResume(t *testing.T, log *Log) {
+	payload := []byte("resumable payload")
+
+	h1, err := log.AppendStream("blob-4", int64(len(payload)), digest.FromBytes(payload))
+	require.NoError(t, err)
+	require.NoError(t, h1.Write(payload[:8], 0))
+
+	// Simulate a crash: a fresh AppendStream call for the same ref should
+	// pick up from the 8 bytes already on disk.
+	h2, err := log.AppendStream("blob-4", int64(len(payload)), digest.FromBytes(payload))
+	require.NoError(t, err)
+	require.Error(t, h2.Write(payload[:1], 0)) // writing at 0 again must be rejected
+	require.NoError(t, h2.Write(payload[8:], 8))
+
+	off, err := h2.Commit()
+	require.NoError(t, err)
+
+	record, err := log.Read(off)
+	require.NoError(t, err)
+	require.Equal(t, payload, record.Value)
+}
+
+func testAppendStreamRejectsPathTraversal(t *testing.T, log *Log) {
+	for _, ref := range []string{
+		"../../etc/cron.d/evil",
+		"/etc/passwd",
+		"..",
+		"sub/dir",
+		"",
+	} {
+		_, err := log.AppendStream(ref, 1, "")
+		require.Error(t, err)
+		_, ok := err.(ErrInvalidRef)
+		require.True(t, ok)
+	}
+}
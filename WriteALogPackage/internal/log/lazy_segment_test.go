@@ -0,0 +1,86 @@
+package log
+
+import (
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+
+	api "github.com/Geun-Oh/distributed-services-with-go/ServeRequestsWithgRPC/api/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLazySegments(t *testing.T) {
+	dir, err := os.MkdirTemp("", "lazy-segment-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := Config{}
+	c.Segment.MaxStoreBytes = 64
+	c.SegmentIdleTimeout = 10 * time.Millisecond
+
+	l, err := NewLog(dir, c)
+	require.NoError(t, err)
+
+	var offsets []uint64
+	for i := 0; i < 200; i++ {
+		off, err := l.Append(&api.Record{Value: []byte("payload")})
+		require.NoError(t, err)
+		offsets = append(offsets, off)
+	}
+	require.Greater(t, len(l.segments), 1, "test needs multiple segments to be meaningful")
+
+	for i := 0; i < 500; i++ {
+		_, err := l.Read(offsets[rand.Intn(len(offsets))])
+		require.NoError(t, err)
+	}
+
+	require.Eventually(t, func() bool {
+		openCount := 0
+		for _, s := range l.segments {
+			if s == l.activeSegment {
+				continue
+			}
+			s.mu.Lock()
+			opened := s.opened
+			s.mu.Unlock()
+			if opened {
+				openCount++
+			}
+		}
+		return openCount == 0
+	}, time.Second, 5*time.Millisecond, "idle non-active segments should close their files")
+}
+
+func TestMaxOpenSegments(t *testing.T) {
+	dir, err := os.MkdirTemp("", "max-open-segments-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := Config{}
+	c.Segment.MaxStoreBytes = 16
+	c.MaxOpenSegments = 1
+	c.SegmentIdleTimeout = time.Hour // rely on proactive eviction, not the timer
+
+	l, err := NewLog(dir, c)
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		_, err := l.Append(&api.Record{Value: []byte("xx")})
+		require.NoError(t, err)
+	}
+	require.Greater(t, len(l.segments), 2)
+
+	openCount := 0
+	for _, s := range l.segments {
+		if s == l.activeSegment {
+			continue
+		}
+		s.mu.Lock()
+		if s.opened {
+			openCount++
+		}
+		s.mu.Unlock()
+	}
+	require.LessOrEqual(t, openCount, c.MaxOpenSegments)
+}
@@ -1,6 +1,8 @@
 package log
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"os"
 	"path"
@@ -8,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	api "github.com/Geun-Oh/distributed-services-with-go/ServeRequestsWithgRPC/api/v1"
 )
@@ -19,11 +22,17 @@ type Log struct {
 
 	activeSegment *segment
 	segments      []*segment
+
+	// appendCond lets WaitForOffset block until Append/AppendAt make more
+	// of the log readable, instead of polling.
+	appendCond *sync.Cond
 }
 
 type originReader struct {
 	*store
-	off int64
+	off     int64
+	release func()
+	done    bool
 }
 
 /*
@@ -39,8 +48,9 @@ func NewLog(dir string, c Config) (*Log, error) {
 		c.Segment.MaxIndexBytes = 1024
 	}
 	l := &Log{
-		Dir:    dir,
-		Config: c,
+		Dir:        dir,
+		Config:     c,
+		appendCond: sync.NewCond(&sync.Mutex{}),
 	}
 
 	return l, l.setup()
@@ -86,11 +96,52 @@ func (l *Log) newSegment(off uint64) error {
 	if err != nil {
 		return err
 	}
+	if err := s.markActive(); err != nil {
+		return err
+	}
+	if l.activeSegment != nil {
+		l.activeSegment.markInactive()
+	}
 	l.segments = append(l.segments, s)
 	l.activeSegment = s
+	l.evictIdleSegments()
 	return nil
 }
 
+// evictIdleSegments proactively closes the least-recently-used idle
+// segment(s) until at most Config.MaxOpenSegments non-active segments are
+// holding their store/index files open. Callers must hold l.mu.
+func (l *Log) evictIdleSegments() {
+	max := l.Config.MaxOpenSegments
+	if max <= 0 {
+		return
+	}
+	for {
+		var (
+			oldest      *segment
+			oldestSince time.Time
+			openCount   int
+		)
+		for _, s := range l.segments {
+			if s == l.activeSegment {
+				continue
+			}
+			since, idle := s.IdleSince()
+			if !idle {
+				continue
+			}
+			openCount++
+			if oldest == nil || since.Before(oldestSince) {
+				oldest, oldestSince = s, since
+			}
+		}
+		if openCount <= max || oldest == nil {
+			return
+		}
+		oldest.closeIfIdle()
+	}
+}
+
 func (l *Log) Append(record *api.Record) (uint64, error) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -101,11 +152,108 @@ func (l *Log) Append(record *api.Record) (uint64, error) {
 			return 0, err
 		}
 	}
-	return l.activeSegment.Append(record)
+	off, err := l.activeSegment.Append(record)
+	if err != nil {
+		return 0, err
+	}
+	l.signalAppend()
+	return off, nil
 }
 
-func (l *Log) Read(off uint64) (*api.Record, error) {
+// AppendAt appends record at exactly offset, rejecting it if offset isn't
+// the next offset the log expects. This is the mode a replication follower
+// uses so its log stays byte-identical to the leader's instead of
+// assigning its own offsets.
+func (l *Log) AppendAt(record *api.Record, offset uint64) (uint64, error) {
 	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	next := l.activeSegment.nextOffset
+	if offset != next {
+		return 0, fmt.Errorf("log: append at offset %d rejected, log expects offset %d", offset, next)
+	}
+	if l.activeSegment.IsMaxed() {
+		if err := l.newSegment(offset); err != nil {
+			return 0, err
+		}
+	}
+	off, err := l.activeSegment.Append(record)
+	if err != nil {
+		return 0, err
+	}
+	l.signalAppend()
+	return off, nil
+}
+
+// NextOffset returns the offset the log will assign to the next appended
+// record. Unlike HighestOffset, it's unambiguous for an empty log.
+func (l *Log) NextOffset() uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.activeSegment.nextOffset
+}
+
+// signalAppend wakes any goroutines blocked in WaitForOffset. Callers must
+// hold l.mu. The broadcast runs on its own goroutine: WaitForOffset takes
+// l.mu while holding appendCond.L, so taking appendCond.L here under l.mu
+// could deadlock.
+func (l *Log) signalAppend() {
+	go func() {
+		l.appendCond.L.Lock()
+		l.appendCond.Broadcast()
+		l.appendCond.L.Unlock()
+	}()
+}
+
+// WaitForOffset blocks until the log's NextOffset is past offset (i.e.
+// offset has been appended and is readable) or ctx is done.
+func (l *Log) WaitForOffset(ctx context.Context, offset uint64) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			l.appendCond.L.Lock()
+			l.appendCond.Broadcast()
+			l.appendCond.L.Unlock()
+		case <-stop:
+		}
+	}()
+
+	l.appendCond.L.Lock()
+	defer l.appendCond.L.Unlock()
+	for l.NextOffset() <= offset {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		l.appendCond.Wait()
+	}
+	return ctx.Err()
+}
+
+// Stream calls send for every record from fromOffset onward, in order,
+// blocking via WaitForOffset whenever the log is caught up, until ctx is
+// canceled, a Read fails, or send returns an error. It is the engine behind replication's
+// leader-side ConsumeStream RPC.
+func (l *Log) Stream(ctx context.Context, fromOffset uint64, send func(*api.Record) error) error {
+	off := fromOffset
+	for {
+		if err := l.WaitForOffset(ctx, off); err != nil {
+			return err
+		}
+		record, err := l.Read(off)
+		if err != nil {
+			return err
+		}
+		if err := send(record); err != nil {
+			return err
+		}
+		off++
+	}
+}
+
+func (l *Log) Read(off uint64) (*api.Record, error) {
+	l.mu.RLock()
 	defer l.mu.RUnlock()
 	var s *segment
 	for _, segment := range l.segments {
@@ -159,13 +307,13 @@ Remove(): 로그를 닫고 데이터를 모두 지운다.
 Reset(): 로그를 제거하고 이를 대체할 새로운 로그를 생성한다.
 */
 func (l *Log) LowestOffset() (uint64, error) {
-	l.mu.Lock()
+	l.mu.RLock()
 	defer l.mu.RUnlock()
 	return l.segments[0].baseOffset, nil
 }
 
 func (l *Log) HighestOffset() (uint64, error) {
-	l.mu.Lock()
+	l.mu.RLock()
 	defer l.mu.RUnlock()
 	off := l.segments[len(l.segments)-1].nextOffset
 	if off == 0 {
@@ -201,17 +349,32 @@ func (l *Log) Truncate(lowest uint64) error {
 */
 
 func (l *Log) Reader() io.Reader {
-	l.mu.Lock()
+	l.mu.RLock()
 	defer l.mu.RUnlock()
 	readers := make([]io.Reader, len(l.segments))
 	for i, segment := range l.segments {
-		readers[i] = &originReader{segment.store, 0}
+		st, _, err := segment.acquire()
+		if err != nil {
+			readers[i] = &errReader{err}
+			continue
+		}
+		readers[i] = &originReader{store: st, release: segment.release}
 	}
 	return io.MultiReader(readers...)
 }
 
+type errReader struct{ err error }
+
+func (r *errReader) Read([]byte) (int, error) { return 0, r.err }
+
 func (o *originReader) Read(p []byte) (int, error) {
 	n, err := o.ReadAt(p, o.off)
 	o.off += int64(n)
+	if err == io.EOF && !o.done {
+		// This segment's reader is exhausted; release the reference
+		// acquired for it so an idle segment can close its files again.
+		o.done = true
+		o.release()
+	}
 	return n, err
 }
@@ -4,57 +4,73 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"sync"
+	"time"
 
 	api "github.com/Geun-Oh/distributed-services-with-go/ServeRequestsWithgRPC/api/v1"
-	"google.golang.org/protobuf/proto"
+	"github.com/Geun-Oh/distributed-services-with-go/internal/common"
+	"github.com/golang/protobuf/proto"
 )
 
+// defaultSegmentIdleTimeout is how long a non-active segment's store and
+// index stay mapped after their last reader releases them, when
+// Config.SegmentIdleTimeout is unset.
+const defaultSegmentIdleTimeout = time.Minute
+
+// tombstonePos marks an index entry whose relative offset was compacted
+// away: the slot stays present (the index format requires a dense run of
+// entries) but points at no store data, so Read/forEachRecord treat it as
+// absent instead of dereferencing it.
+const tombstonePos = ^uint64(0)
+
 type segment struct {
-	store                  *store // 저장 파일
-	index                  *index // 인덱스 파일
+	dir                    string
 	baseOffset, nextOffset uint64
 	config                 Config
+
+	storeRef *common.RefCount[*store]
+	indexRef *common.RefCount[*index]
+
+	mu       sync.Mutex
+	opened   bool
+	active   bool
+	idleTime *time.Timer
+	lastUsed time.Time
 }
 
 /*
 세그먼트는 내부의 스토어와 인덱스를 호출해야하므로 처음 두 필드에 각 포인터를 가진다.
 베이스가 되는 오프셋과 다음에 추가할 오프셋 값도 가지는데, 인덱스 항목의 상대 오프셋을 계산하고 다음 항목을 추가할 때 사용한다.
 config 필드를 두어 저장 파일과 인덱스 파일의 크기를 설정의 최댓값과 비교할 수 있으므로 세그먼트가 가득 찼는지 알 수 있도록 한다.
+
+Unlike the active segment, a read-only segment does not keep its store and
+index file handles open between calls. acquire/release wrap storeRef and
+indexRef with open-on-demand and idle-close behavior: the first acquire
+after the files are closed reopens them, and once every caller has released
+its reference, an idle timer (Config.SegmentIdleTimeout) actually closes the
+underlying files so a Log with many segments doesn't hold thousands of file
+descriptors and mmaps open at once.
 */
 
 func newSegment(dir string, baseOffset uint64, c Config) (*segment, error) {
 	s := &segment{
+		dir:        dir,
 		baseOffset: baseOffset,
 		config:     c,
+		storeRef:   &common.RefCount[*store]{},
+		indexRef:   &common.RefCount[*index]{},
 	}
-	var err error
-	storeFile, err := os.OpenFile(
-		path.Join(dir, fmt.Sprintf("%d%s", baseOffset, ".store")),
-		os.O_RDWR|os.O_CREATE|os.O_APPEND,
-		0644,
-	)
-	if err != nil {
-		return nil, err
-	}
-	if s.store, err = newStore(storeFile); err != nil {
-		return nil, err
-	}
-	indexFile, err := os.OpenFile(
-		path.Join(dir, fmt.Sprintf("%d%s", baseOffset, ".index")),
-		os.O_RDWR|os.O_CREATE,
-		0644,
-	)
+
+	_, idx, err := s.acquire()
 	if err != nil {
 		return nil, err
 	}
-	if s.index, err = newIndex(indexFile, c); err != nil {
-		return nil, err
-	}
-	if off, _, err := s.index.Read(-1); err != nil {
+	if off, _, err := idx.Read(-1); err != nil {
 		s.nextOffset = baseOffset
 	} else {
 		s.nextOffset = baseOffset + uint64(off) + 1
 	}
+	s.release()
 	return s, nil
 }
 
@@ -68,7 +84,149 @@ func newSegment(dir string, baseOffset uint64, c Config) (*segment, error) {
 이 값은 베이스 오프셋과 상대 오프셋에 1을 더하여 구한다.
 */
 
+// open reopens the segment's store and index files if they aren't already
+// open. Callers must hold s.mu.
+func (s *segment) open() error {
+	if s.opened {
+		return nil
+	}
+	storeFile, err := os.OpenFile(
+		path.Join(s.dir, fmt.Sprintf("%d%s", s.baseOffset, ".store")),
+		os.O_RDWR|os.O_CREATE|os.O_APPEND,
+		0644,
+	)
+	if err != nil {
+		return err
+	}
+	st, err := newStore(storeFile)
+	if err != nil {
+		return err
+	}
+	indexFile, err := os.OpenFile(
+		path.Join(s.dir, fmt.Sprintf("%d%s", s.baseOffset, ".index")),
+		os.O_RDWR|os.O_CREATE,
+		0644,
+	)
+	if err != nil {
+		return err
+	}
+	idx, err := newIndex(indexFile, s.config)
+	if err != nil {
+		return err
+	}
+	s.storeRef.Set(st)
+	s.indexRef.Set(idx)
+	s.opened = true
+	return nil
+}
+
+// markActive keeps this segment's store and index open permanently, as the
+// log's active segment never goes idle.
+func (s *segment) markActive() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active {
+		return nil
+	}
+	if err := s.open(); err != nil {
+		return err
+	}
+	s.active = true
+	return nil
+}
+
+// markInactive lets the segment idle out like any other read-only segment
+// once it's no longer the log's active one. It does not itself hold a
+// reference, so it arms the idle timer directly rather than going through
+// release's refcount bookkeeping.
+func (s *segment) markInactive() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active = false
+	if s.storeRef.RefCnt() > 0 || s.indexRef.RefCnt() > 0 {
+		return // a concurrent Read/Append is in flight; it will arm the timer on release
+	}
+	timeout := s.config.SegmentIdleTimeout
+	if timeout == 0 {
+		timeout = defaultSegmentIdleTimeout
+	}
+	s.lastUsed = time.Now()
+	s.idleTime = time.AfterFunc(timeout, s.closeIfIdle)
+}
+
+// acquire opens (or reuses) the segment's store and index and disarms any
+// pending idle-close timer. Callers must pair it with release.
+//
+// The refcount increments happen while s.mu is still held, in the same
+// critical section as the open-check: releasing s.mu first would let
+// closeIfIdle (which also waits on s.mu) observe both refcounts still at
+// zero and close the files out from under this call before it gets a
+// chance to bump them.
+func (s *segment) acquire() (*store, *index, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.idleTime != nil {
+		s.idleTime.Stop()
+		s.idleTime = nil
+	}
+	if err := s.open(); err != nil {
+		return nil, nil, err
+	}
+	return s.storeRef.Acquire(), s.indexRef.Acquire(), nil
+}
+
+// release drops the reference acquire took. Once both the store and index
+// refcounts reach zero, an idle timer is armed that actually closes the
+// segment's files after Config.SegmentIdleTimeout.
+func (s *segment) release() {
+	storeCnt := s.storeRef.Close()
+	indexCnt := s.indexRef.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastUsed = time.Now()
+	if s.active || storeCnt > 0 || indexCnt > 0 {
+		return
+	}
+	timeout := s.config.SegmentIdleTimeout
+	if timeout == 0 {
+		timeout = defaultSegmentIdleTimeout
+	}
+	s.idleTime = time.AfterFunc(timeout, s.closeIfIdle)
+}
+
+// closeIfIdle actually closes the segment's files if nothing has reacquired
+// a reference since the idle timer was armed.
+func (s *segment) closeIfIdle() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active || !s.opened || s.storeRef.RefCnt() > 0 || s.indexRef.RefCnt() > 0 {
+		return
+	}
+	s.indexRef.Get().Close()
+	s.storeRef.Get().Close()
+	s.opened = false
+}
+
+// IdleSince reports when the segment last released its last reference, for
+// Log's MaxOpenSegments LRU eviction. The second return is false while the
+// segment is active, closed, or currently in use.
+func (s *segment) IdleSince() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active || !s.opened || s.storeRef.RefCnt() > 0 || s.indexRef.RefCnt() > 0 {
+		return time.Time{}, false
+	}
+	return s.lastUsed, true
+}
+
 func (s *segment) Append(record *api.Record) (offset uint64, err error) {
+	st, idx, err := s.acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer s.release()
+
 	cur := s.nextOffset
 	record.Offset = cur
 	p, err := proto.Marshal(record)
@@ -76,11 +234,11 @@ func (s *segment) Append(record *api.Record) (offset uint64, err error) {
 		return 0, err
 	}
 
-	_, pos, err := s.store.Append(p)
+	_, pos, err := st.Append(p)
 	if err != nil {
 		return 0, err
 	}
-	if err = s.index.Write(
+	if err = idx.Write(
 		// 인덱스의 오프셋은 베이스 오프셋에서의 상댓값이다.
 		uint32(s.nextOffset-uint64(s.baseOffset)),
 		pos,
@@ -98,12 +256,56 @@ Append 메서드는 세그먼트에 레코드를 쓰고, 추가한 레코드의
 이후 다음 추가를 대비해서 다음 오프셋을 하나 증가시킨다.
 */
 
+// appendAtRelativeOffset writes record to the store and points index slot
+// rel at it, without touching s.nextOffset. It's used only while rebuilding
+// a segment during compaction, where the caller is replaying the original
+// segment's relative offsets in order rather than assigning new ones.
+func (s *segment) appendAtRelativeOffset(rel uint32, record *api.Record) error {
+	st, idx, err := s.acquire()
+	if err != nil {
+		return err
+	}
+	defer s.release()
+
+	p, err := proto.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, pos, err := st.Append(p)
+	if err != nil {
+		return err
+	}
+	return idx.Write(rel, pos)
+}
+
+// appendTombstoneEntry records relative offset rel as compacted away: a
+// dense index entry still exists for it (so later slots keep their
+// position), but it's flagged with tombstonePos instead of pointing at a
+// record in the store.
+func (s *segment) appendTombstoneEntry(rel uint32) error {
+	_, idx, err := s.acquire()
+	if err != nil {
+		return err
+	}
+	defer s.release()
+	return idx.Write(rel, tombstonePos)
+}
+
 func (s *segment) Read(off uint64) (*api.Record, error) {
-	_, pos, err := s.index.Read(int64(off - s.baseOffset))
+	st, idx, err := s.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer s.release()
+
+	_, pos, err := idx.Read(int64(off - s.baseOffset))
 	if err != nil {
 		return nil, err
 	}
-	p, err := s.store.Read(pos)
+	if pos == tombstonePos {
+		return nil, api.ErrOffsetOutOfRange{Offset: off}
+	}
+	p, err := st.Read(pos)
 	if err != nil {
 		return nil, err
 	}
@@ -119,7 +321,12 @@ Read 메서드는 오프셋의 레코드를 리턴한다. 읽기와 비슷하게
 */
 
 func (s *segment) IsMaxed() bool {
-	return s.store.size >= s.config.Segment.MaxStoreBytes || s.index.size+entWidth > s.config.Segment.MaxIndexBytes
+	st, idx, err := s.acquire()
+	if err != nil {
+		return false
+	}
+	defer s.release()
+	return st.size >= s.config.Segment.MaxStoreBytes || idx.size+entWidth > s.config.Segment.MaxIndexBytes
 }
 
 /*
@@ -132,21 +339,31 @@ func (s *segment) Remove() error {
 	if err := s.Close(); err != nil {
 		return err
 	}
-	if err := os.Remove(s.index.Name()); err != nil {
+	if err := os.Remove(path.Join(s.dir, fmt.Sprintf("%d%s", s.baseOffset, ".index"))); err != nil {
 		return err
 	}
-	if err := os.Remove(s.store.Name()); err != nil {
+	if err := os.Remove(path.Join(s.dir, fmt.Sprintf("%d%s", s.baseOffset, ".store"))); err != nil {
 		return err
 	}
 	return nil
 }
 
 func (s *segment) Close() error {
-	if err := s.index.Close(); err != nil {
-		return err
+	s.mu.Lock()
+	if s.idleTime != nil {
+		s.idleTime.Stop()
+		s.idleTime = nil
 	}
-	if err := s.store.Close(); err != nil {
+	opened := s.opened
+	s.active = false
+	s.opened = false
+	s.mu.Unlock()
+
+	if !opened {
+		return nil
+	}
+	if err := s.indexRef.Get().Close(); err != nil {
 		return err
 	}
-	return nil
+	return s.storeRef.Get().Close()
 }
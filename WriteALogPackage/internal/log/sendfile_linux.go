@@ -0,0 +1,76 @@
+//go:build linux
+
+package log
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// trySendfile attempts to transfer n bytes starting at offset from src
+// directly to dst using the sendfile(2) syscall, without copying through a
+// Go-managed buffer. handled is false when dst doesn't expose a raw
+// descriptor sendfile can target (i.e. it isn't a syscall.Conn, such as
+// *net.TCPConn or *os.File), so the caller can fall back to a normal copy.
+func trySendfile(dst io.Writer, src *os.File, offset, n int64) (written int64, handled bool, err error) {
+	rc, ok := destConn(dst)
+	if !ok {
+		return 0, false, nil
+	}
+
+	off := offset
+	remaining := n
+	var sendErr error
+	writeErr := rc.Write(func(fd uintptr) (done bool) {
+		for remaining > 0 {
+			w, serr := syscall.Sendfile(int(fd), int(src.Fd()), &off, int(remaining))
+			if serr != nil {
+				if serr == syscall.EAGAIN {
+					// Tell RawConn.Write to poll for writability and call
+					// us again, instead of busy-looping here.
+					return false
+				}
+				if serr == syscall.EINTR {
+					continue
+				}
+				sendErr = serr
+				return true
+			}
+			if w == 0 {
+				return true
+			}
+			written += int64(w)
+			remaining -= int64(w)
+		}
+		return true
+	})
+	if writeErr != nil {
+		return written, true, writeErr
+	}
+	return written, true, sendErr
+}
+
+/*
+destConn extracts a syscall.RawConn for dst's underlying descriptor, if any.
+
+An earlier version of this used *net.TCPConn.File() to reach the fd, but
+File() dup()s the descriptor AND permanently switches the original conn into
+blocking mode, which knocks it off the runtime's netpoller — a serious
+regression for long-lived connections such as consumers or replication
+followers, which keep reading/writing that same conn after this call
+returns. dst.(syscall.Conn).SyscallConn() gives the raw fd without either
+side effect, and RawConn.Write cooperates with the netpoller on EAGAIN
+instead of duplicating anything.
+*/
+func destConn(dst io.Writer) (syscall.RawConn, bool) {
+	sc, ok := dst.(syscall.Conn)
+	if !ok {
+		return nil, false
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return nil, false
+	}
+	return rc, true
+}
@@ -0,0 +1,81 @@
+//go:build linux
+
+package log
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	api "github.com/Geun-Oh/distributed-services-with-go/ServeRequestsWithgRPC/api/v1"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWriteToSendfileOverSocket drives Log.WriteTo's sendfile(2) path over a
+// genuine TCP socket pair; writeto_test.go's destination is a bytes.Buffer,
+// which never exposes a raw fd and so never reaches trySendfile's sendfile
+// branch at all.
+func TestWriteToSendfileOverSocket(t *testing.T) {
+	dir := t.TempDir()
+	c := Config{}
+	c.Segment.MaxStoreBytes = 1024
+	l, err := NewLog(dir, c)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := l.Append(&api.Record{Value: []byte("hello world")})
+		require.NoError(t, err)
+	}
+
+	var expected bytes.Buffer
+	_, err = l.WriteTo(&expected, 1)
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	type result struct {
+		conn *net.TCPConn
+		n    int64
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		tc := conn.(*net.TCPConn)
+		n, err := l.WriteTo(tc, 1)
+		results <- result{conn: tc, n: n, err: err}
+	}()
+
+	clientConn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer clientConn.Close()
+
+	got := make([]byte, expected.Len())
+	_, err = io.ReadFull(clientConn, got)
+	require.NoError(t, err)
+	require.Equal(t, expected.Bytes(), got)
+
+	res := <-results
+	require.NoError(t, res.err)
+	require.Equal(t, int64(expected.Len()), res.n)
+	require.NotNil(t, res.conn)
+	defer res.conn.Close()
+
+	// Regression check for the TCPConn.File()-based implementation this
+	// replaces: File() duplicates the fd and permanently switches the
+	// original conn into blocking mode, which breaks deadline-based reads
+	// on that conn afterwards. If that regression were still present, this
+	// read would hang past the deadline instead of timing out.
+	require.NoError(t, res.conn.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+	_, err = res.conn.Read(make([]byte, 1))
+	netErr, ok := err.(net.Error)
+	require.True(t, ok, "expected a net.Error, got %v", err)
+	require.True(t, netErr.Timeout())
+}
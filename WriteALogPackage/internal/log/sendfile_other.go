@@ -0,0 +1,14 @@
+//go:build !linux
+
+package log
+
+import (
+	"io"
+	"os"
+)
+
+// trySendfile is a no-op on platforms without sendfile(2); callers always
+// fall back to a normal copy.
+func trySendfile(dst io.Writer, src *os.File, offset, n int64) (written int64, handled bool, err error) {
+	return 0, false, nil
+}
@@ -0,0 +1,52 @@
+// Package common holds small concurrency helpers shared across the
+// project's chapters.
+package common
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// RefCount tracks concurrent users of a lazily-set value of type T. It
+// holds no opinion on when T is created or torn down — callers Set the
+// value once it's open, Acquire/Close around each use, and watch RefCnt to
+// decide when it's safe to actually close T. It is safe for concurrent use.
+type RefCount[T io.Closer] struct {
+	cnt atomic.Int32
+
+	mu  sync.Mutex
+	val T
+}
+
+// Acquire increments the reference count and returns the current value.
+func (r *RefCount[T]) Acquire() T {
+	r.cnt.Add(1)
+	return r.Get()
+}
+
+// Close decrements the reference count and returns the count left after the
+// decrement. It never closes the underlying value itself — that decision,
+// and the actual io.Closer.Close call, belong to the owner watching RefCnt.
+func (r *RefCount[T]) Close() int32 {
+	return r.cnt.Add(-1)
+}
+
+// RefCnt returns the current reference count.
+func (r *RefCount[T]) RefCnt() int32 {
+	return r.cnt.Load()
+}
+
+// Get returns the value currently held, regardless of reference count.
+func (r *RefCount[T]) Get() T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.val
+}
+
+// Set replaces the held value, e.g. after reopening it.
+func (r *RefCount[T]) Set(v T) {
+	r.mu.Lock()
+	r.val = v
+	r.mu.Unlock()
+}
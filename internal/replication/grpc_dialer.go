@@ -0,0 +1,44 @@
+package replication
+
+import (
+	"context"
+
+	api "github.com/Geun-Oh/distributed-services-with-go/ServeRequestsWithgRPC/api/v1"
+	"google.golang.org/grpc"
+)
+
+// GRPCDialer is the production Dialer: it dials addr with grpc.DialContext
+// and opens a ConsumeStream on the resulting client.
+type GRPCDialer struct {
+	DialOptions []grpc.DialOption
+}
+
+func (d *GRPCDialer) DialConsumeStream(ctx context.Context, addr string, fromOffset uint64) (RecordStream, error) {
+	cc, err := grpc.DialContext(ctx, addr, d.DialOptions...)
+	if err != nil {
+		return nil, err
+	}
+	client := api.NewLogClient(cc)
+	stream, err := client.ConsumeStream(ctx, &api.ConsumeRequest{Offset: fromOffset})
+	if err != nil {
+		cc.Close()
+		return nil, err
+	}
+	return &closingStream{stream: stream, conn: cc}, nil
+}
+
+// closingStream closes the underlying connection once the stream ends, so
+// Replicator doesn't need to know about *grpc.ClientConn at all.
+type closingStream struct {
+	stream api.Log_ConsumeStreamClient
+	conn   *grpc.ClientConn
+}
+
+func (s *closingStream) Recv() (*api.Record, error) {
+	resp, err := s.stream.Recv()
+	if err != nil {
+		s.conn.Close()
+		return nil, err
+	}
+	return resp.Record, nil
+}
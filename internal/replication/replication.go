@@ -0,0 +1,197 @@
+// Package replication lets one node act as a leader whose log other nodes
+// tail asynchronously, staying byte-identical to it.
+package replication
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	api "github.com/Geun-Oh/distributed-services-with-go/ServeRequestsWithgRPC/api/v1"
+)
+
+const (
+	initialBackoff = 100 * time.Millisecond
+	maxBackoff     = 10 * time.Second
+)
+
+// CommitLog is the subset of *log.Log (the WriteALogPackage package) a
+// follower needs to mirror a leader. It's declared here instead of
+// importing the concrete type so tests can fake it without real segment
+// files.
+type CommitLog interface {
+	AppendAt(record *api.Record, offset uint64) (uint64, error)
+	NextOffset() uint64
+}
+
+// RecordStream is the client-side handle for a ConsumeStream call: Recv
+// returns records in offset order and io.EOF once the leader closes the
+// stream.
+type RecordStream interface {
+	Recv() (*api.Record, error)
+}
+
+// Dialer opens a ConsumeStream to a peer starting at fromOffset. It
+// abstracts over the concrete gRPC client (api.LogClient.ConsumeStream) so
+// the replicator can be tested without a network.
+type Dialer interface {
+	DialConsumeStream(ctx context.Context, addr string, fromOffset uint64) (RecordStream, error)
+}
+
+// Replicator tails one or more peers' logs into Local, preserving the
+// leader's original offsets so every replica ends up byte-identical.
+type Replicator struct {
+	Dialer Dialer
+	Local  CommitLog
+	Logger *log.Logger
+
+	mu     sync.Mutex
+	closed bool
+	peers  map[string]func()
+}
+
+// NewReplicator builds a Replicator that dials peers with dialer and
+// applies what it receives to local.
+func NewReplicator(dialer Dialer, local CommitLog) *Replicator {
+	return &Replicator{
+		Dialer: dialer,
+		Local:  local,
+		Logger: log.Default(),
+		peers:  make(map[string]func()),
+	}
+}
+
+// Join starts tailing the peer registered under name at addr, from the
+// local log's current end. It's a no-op if name is already being
+// replicated.
+func (r *Replicator) Join(name, addr string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return fmt.Errorf("replication: replicator is closed")
+	}
+	if _, ok := r.peers[name]; ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.peers[name] = cancel
+	go r.replicate(ctx, name, addr)
+	return nil
+}
+
+// Leave stops tailing the peer registered under name. It's a no-op if name
+// isn't being replicated.
+func (r *Replicator) Leave(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cancel, ok := r.peers[name]
+	if !ok {
+		return nil
+	}
+	delete(r.peers, name)
+	cancel()
+	return nil
+}
+
+// Close stops replicating every peer.
+func (r *Replicator) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	for name, cancel := range r.peers {
+		delete(r.peers, name)
+		cancel()
+	}
+	return nil
+}
+
+// replicate dials addr and applies every record it streams back to Local,
+// reconnecting with exponential backoff until ctx is canceled by Leave or
+// Close. Offsets already present in Local (e.g. replayed after a restart)
+// are silently skipped rather than treated as a fatal gap.
+func (r *Replicator) replicate(ctx context.Context, name, addr string) {
+	backoff := initialBackoff
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		from := r.Local.NextOffset()
+		stream, err := r.Dialer.DialConsumeStream(ctx, addr, from)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			r.Logger.Printf("replication: dial %s (%s) failed: %v, retrying in %s", name, addr, err, backoff)
+			if !sleep(ctx, backoff) {
+				return
+			}
+			backoff = nextBackoff(backoff)
+			continue
+		}
+
+		err = r.drain(stream)
+		if ctx.Err() != nil {
+			return
+		}
+		if r.Local.NextOffset() > from {
+			backoff = initialBackoff // the stream made progress
+		}
+		if err != nil {
+			r.Logger.Printf("replication: stream from %s (%s) ended: %v, reconnecting in %s", name, addr, err, backoff)
+		}
+		if !sleep(ctx, backoff) {
+			return
+		}
+		backoff = nextBackoff(backoff)
+	}
+}
+
+// drain applies every record Recv returns to Local until the stream ends.
+func (r *Replicator) drain(stream RecordStream) error {
+	for {
+		record, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if record.Offset < r.Local.NextOffset() {
+			continue // already applied, e.g. after a restart; dedupe by offset
+		}
+		if _, err := r.Local.AppendAt(record, record.Offset); err != nil {
+			return err
+		}
+	}
+}
+
+func nextBackoff(cur time.Duration) time.Duration {
+	next := cur * 2
+	if next > maxBackoff {
+		return maxBackoff
+	}
+	return next
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,152 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	api "github.com/Geun-Oh/distributed-services-with-go/ServeRequestsWithgRPC/api/v1"
+	wlog "github.com/Geun-Oh/distributed-services-with-go/WriteALogPackage/internal/log"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStream streams a node's own log to whoever dialed it, so the test can
+// exercise Replicator end-to-end without a real network or gRPC service.
+type fakeStream struct {
+	ctx context.Context
+	src *wlog.Log
+	off uint64
+}
+
+func (s *fakeStream) Recv() (*api.Record, error) {
+	if err := s.src.WaitForOffset(s.ctx, s.off); err != nil {
+		return nil, io.EOF
+	}
+	record, err := s.src.Read(s.off)
+	if err != nil {
+		return nil, err
+	}
+	s.off++
+	return record, nil
+}
+
+// fakeDialer routes a ConsumeStream request to whichever in-memory node
+// registered under addr.
+type fakeDialer struct {
+	mu    sync.Mutex
+	nodes map[string]*wlog.Log
+}
+
+func (d *fakeDialer) DialConsumeStream(ctx context.Context, addr string, fromOffset uint64) (RecordStream, error) {
+	d.mu.Lock()
+	src, ok := d.nodes[addr]
+	d.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("replication test: no node at %s", addr)
+	}
+	return &fakeStream{ctx: ctx, src: src, off: fromOffset}, nil
+}
+
+func newTestLog(t *testing.T) *wlog.Log {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "replication-test")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	c := wlog.Config{}
+	c.Segment.MaxStoreBytes = 1024
+
+	l, err := wlog.NewLog(dir, c)
+	require.NoError(t, err)
+	return l
+}
+
+func TestReplicationConvergence(t *testing.T) {
+	leader := newTestLog(t)
+	follower1 := newTestLog(t)
+	follower2 := newTestLog(t)
+
+	dialer := &fakeDialer{nodes: map[string]*wlog.Log{
+		"leader": leader,
+	}}
+
+	rep1 := NewReplicator(dialer, follower1)
+	rep2 := NewReplicator(dialer, follower2)
+	t.Cleanup(func() {
+		rep1.Close()
+		rep2.Close()
+	})
+	require.NoError(t, rep1.Join("leader", "leader"))
+	require.NoError(t, rep2.Join("leader", "leader"))
+
+	const total = 50
+	for i := 0; i < total; i++ {
+		_, err := leader.Append(&api.Record{Value: []byte(fmt.Sprintf("record-%d", i))})
+		require.NoError(t, err)
+	}
+
+	require.Eventually(t, func() bool {
+		return follower1.NextOffset() >= total && follower2.NextOffset() >= total
+	}, 2*time.Second, 10*time.Millisecond)
+
+	for i := 0; i < total; i++ {
+		want, err := leader.Read(uint64(i))
+		require.NoError(t, err)
+		got1, err := follower1.Read(uint64(i))
+		require.NoError(t, err)
+		got2, err := follower2.Read(uint64(i))
+		require.NoError(t, err)
+		require.Equal(t, want.Value, got1.Value)
+		require.Equal(t, want.Value, got2.Value)
+		require.Equal(t, uint64(i), got1.Offset)
+		require.Equal(t, uint64(i), got2.Offset)
+	}
+}
+
+func TestReplicationSurvivesRandomAppendsAndRestart(t *testing.T) {
+	leader := newTestLog(t)
+	follower := newTestLog(t)
+
+	dialer := &fakeDialer{nodes: map[string]*wlog.Log{"leader": leader}}
+	rep := NewReplicator(dialer, follower)
+
+	require.NoError(t, rep.Join("leader", "leader"))
+	for i := 0; i < 20; i++ {
+		_, err := leader.Append(&api.Record{Value: []byte(fmt.Sprintf("before-%d", i))})
+		require.NoError(t, err)
+		time.Sleep(time.Duration(rand.Intn(2)) * time.Millisecond)
+	}
+	require.Eventually(t, func() bool {
+		return follower.NextOffset() >= 20
+	}, time.Second, 5*time.Millisecond)
+
+	// Simulate the follower's process restarting: a fresh Replicator
+	// against the same on-disk log must resume from where it left off
+	// without re-applying or gapping any offset.
+	require.NoError(t, rep.Leave("leader"))
+	rep2 := NewReplicator(dialer, follower)
+	t.Cleanup(func() { rep2.Close() })
+	require.NoError(t, rep2.Join("leader", "leader"))
+
+	for i := 20; i < 40; i++ {
+		_, err := leader.Append(&api.Record{Value: []byte(fmt.Sprintf("after-%d", i))})
+		require.NoError(t, err)
+	}
+
+	require.Eventually(t, func() bool {
+		return follower.NextOffset() >= 40
+	}, 2*time.Second, 10*time.Millisecond)
+
+	for i := 0; i < 40; i++ {
+		want, err := leader.Read(uint64(i))
+		require.NoError(t, err)
+		got, err := follower.Read(uint64(i))
+		require.NoError(t, err)
+		require.Equal(t, want.Value, got.Value)
+	}
+}